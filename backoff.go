@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// backoffState tracks the exponential-backoff attempt count for a single
+// price provider, used when upstream signals a rate limit or an outage.
+type backoffState struct {
+	mu      sync.Mutex
+	attempt int
+	base    time.Duration
+}
+
+// newBackoffState creates a backoff tracker starting from the given base
+// duration, typically the provider's configured refresh interval.
+func newBackoffState(base time.Duration) *backoffState {
+	return &backoffState{base: base}
+}
+
+// Next returns how long to wait before retrying. A positive retryAfter
+// (parsed from the upstream's Retry-After header) is honored as-is and
+// resets the attempt counter; otherwise it falls back to exponential
+// backoff, doubling on every call and capped at 5 minutes, plus a random
+// jitter of up to half that wait (so at most 7.5 minutes in all).
+// The attempt counter stops growing once the cap is reached, so a
+// sustained streak of failures can never shift b.base into overflow.
+func (b *backoffState) Next(retryAfter time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if retryAfter > 0 {
+		b.attempt = 0
+		return retryAfter
+	}
+
+	const maxWait = 5 * time.Minute
+
+	wait := maxWait
+	if candidate := b.base * time.Duration(1<<uint(b.attempt)); candidate > 0 && candidate < maxWait {
+		wait = candidate
+		b.attempt++
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(wait)/2 + 1))
+	return wait + jitter
+}
+
+// Reset clears the attempt counter after a successful fetch.
+func (b *backoffState) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attempt = 0
+}
+
+// RateLimitError signals that a provider asked the caller to back off for
+// a specific duration before retrying, instead of the usual refresh interval.
+type RateLimitError struct {
+	Provider string
+	Wait     time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: rate limited, backing off %s", e.Provider, e.Wait)
+}
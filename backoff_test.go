@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffStateHonorsRetryAfter(t *testing.T) {
+	b := newBackoffState(time.Second)
+
+	wait := b.Next(30 * time.Second)
+	assert.Equal(t, 30*time.Second, wait)
+}
+
+func TestBackoffStateGrowsExponentially(t *testing.T) {
+	b := newBackoffState(time.Second)
+
+	first := b.Next(0)
+	second := b.Next(0)
+
+	assert.GreaterOrEqual(t, first, time.Second)
+	assert.GreaterOrEqual(t, second, 2*time.Second)
+}
+
+func TestBackoffStateResetClearsAttempts(t *testing.T) {
+	b := newBackoffState(time.Second)
+
+	b.Next(0)
+	b.Next(0)
+	b.Reset()
+
+	wait := b.Next(0)
+	assert.GreaterOrEqual(t, wait, time.Second)
+	assert.Less(t, wait, 2*time.Second)
+}
+
+// TestBackoffStateSurvivesSustainedFailures guards against the overflow
+// panic: a long streak of failures must stay at maxWait plus jitter instead
+// of shifting b.base into a negative or zero duration.
+func TestBackoffStateSurvivesSustainedFailures(t *testing.T) {
+	b := newBackoffState(time.Second)
+
+	var wait time.Duration
+	assert.NotPanics(t, func() {
+		for i := 0; i < 100; i++ {
+			wait = b.Next(0)
+		}
+	})
+
+	assert.GreaterOrEqual(t, wait, 5*time.Minute)
+	assert.Less(t, wait, 10*time.Minute)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// cacheEntry holds a single cached price and when it was fetched.
+type cacheEntry struct {
+	price     float64
+	fetchedAt time.Time
+}
+
+// PriceCache is a per-(token, vs_currency) TTL cache. It lets on-demand
+// handlers and the periodic refresh loop reuse a still-fresh price
+// instead of triggering another upstream call.
+type PriceCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+// NewPriceCache builds an empty cache with the given freshness window.
+func NewPriceCache(ttl time.Duration) *PriceCache {
+	return &PriceCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func cacheKey(id, vs string) string {
+	return id + "|" + vs
+}
+
+// Get returns the cached price for (id, vs), if it was set within the TTL.
+func (c *PriceCache) Get(id, vs string) (float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[cacheKey(id, vs)]
+	if !ok || time.Since(entry.fetchedAt) > c.ttl {
+		return 0, false
+	}
+	return entry.price, true
+}
+
+// Set records a freshly fetched price for (id, vs).
+func (c *PriceCache) Set(id, vs string, price float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey(id, vs)] = cacheEntry{price: price, fetchedAt: time.Now()}
+}
+
+// Fresh reports whether every requested vs currency for id has a
+// non-expired cache entry.
+func (c *PriceCache) Fresh(id string, vsCurrencies []string) bool {
+	for _, vs := range vsCurrencies {
+		if _, ok := c.Get(id, vs); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceCacheGetSet(t *testing.T) {
+	cache := NewPriceCache(time.Minute)
+
+	_, ok := cache.Get("bitcoin", "usd")
+	assert.False(t, ok)
+
+	cache.Set("bitcoin", "usd", 67000)
+	price, ok := cache.Get("bitcoin", "usd")
+	assert.True(t, ok)
+	assert.Equal(t, 67000.0, price)
+}
+
+func TestPriceCacheExpires(t *testing.T) {
+	cache := NewPriceCache(10 * time.Millisecond)
+	cache.Set("bitcoin", "usd", 67000)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok := cache.Get("bitcoin", "usd")
+	assert.False(t, ok)
+}
+
+func TestPriceCacheFreshRequiresAllVsCurrencies(t *testing.T) {
+	cache := NewPriceCache(time.Minute)
+	cache.Set("bitcoin", "usd", 67000)
+
+	assert.True(t, cache.Fresh("bitcoin", []string{"usd"}))
+	assert.False(t, cache.Fresh("bitcoin", []string{"usd", "eur"}))
+}
@@ -0,0 +1,73 @@
+package main
+
+// fiatToUSD holds coarse static exchange rates (the USD value of one unit
+// of fiat), used as a fallback hub to derive vs_currencies that a provider
+// didn't return directly. These are not live rates.
+var fiatToUSD = map[string]float64{
+	"usd": 1.0,
+	"eur": 1.08,
+	"gbp": 1.27,
+	"jpy": 0.0064,
+	"chf": 1.12,
+}
+
+// vsCurrencyTokenAlias maps a short vs_currency ticker to the CoinGecko
+// token id carrying its USD price, so a crypto-to-crypto cross rate can be
+// derived even when --vs-currencies uses the short form (btc, eth) rather
+// than the full id (bitcoin, ethereum) used to key the prices map.
+var vsCurrencyTokenAlias = map[string]string{
+	"btc": "bitcoin",
+	"eth": "ethereum",
+}
+
+// tokenIDFor resolves a vs_currency to the token id it should be looked up
+// under in the prices map, following vsCurrencyTokenAlias when set.
+func tokenIDFor(vs string) string {
+	if id, ok := vsCurrencyTokenAlias[vs]; ok {
+		return id
+	}
+	return vs
+}
+
+// CrossRateResolver derives prices for vs_currencies missing from an
+// upstream response by walking known pairs through USD as a hub, e.g.
+// BTC/EUR = BTC/USD ÷ EUR/USD, or TOKEN/ETH = TOKEN/USD ÷ ETH/USD.
+type CrossRateResolver struct {
+	FiatToUSD map[string]float64
+}
+
+// NewCrossRateResolver builds a resolver backed by the default fiat table.
+func NewCrossRateResolver() *CrossRateResolver {
+	return &CrossRateResolver{FiatToUSD: fiatToUSD}
+}
+
+// ResolveAll fills in any vs currencies missing from the fetched prices,
+// deriving them through USD when possible. It mutates prices in place so
+// callers can request arbitrary (token, vs) combinations without extra
+// API calls.
+func (r *CrossRateResolver) ResolveAll(prices map[string]CryptoCurrencyData, vsCurrencies []string) {
+	for token, data := range prices {
+		usdPrice, haveUSD := data["usd"]
+		if !haveUSD {
+			continue
+		}
+
+		for _, vs := range vsCurrencies {
+			if _, ok := data[vs]; ok {
+				continue
+			}
+
+			switch {
+			case vs == "usd":
+				data[vs] = usdPrice
+			case prices[tokenIDFor(vs)]["usd"] != 0:
+				// Crypto-to-crypto cross rate, e.g. vs == "ethereum" or "eth"
+				data[vs] = usdPrice / prices[tokenIDFor(vs)]["usd"]
+			case r.FiatToUSD[vs] != 0:
+				data[vs] = usdPrice / r.FiatToUSD[vs]
+			}
+		}
+
+		prices[token] = data
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCrossRateResolverDerivesFiat checks that a missing fiat vs_currency
+// is derived from USD via the static fiat table
+func TestCrossRateResolverDerivesFiat(t *testing.T) {
+	resolver := NewCrossRateResolver()
+	prices := map[string]CryptoCurrencyData{
+		"bitcoin": {"usd": 67000},
+	}
+
+	resolver.ResolveAll(prices, []string{"usd", "eur"})
+
+	assert.InDelta(t, 62037.0, prices["bitcoin"]["eur"], 1.0)
+}
+
+// TestCrossRateResolverDerivesCryptoCross checks that a vs_currency that is
+// itself one of the fetched tokens is derived through USD
+func TestCrossRateResolverDerivesCryptoCross(t *testing.T) {
+	resolver := NewCrossRateResolver()
+	prices := map[string]CryptoCurrencyData{
+		"bitcoin":  {"usd": 67000},
+		"ethereum": {"usd": 2600},
+	}
+
+	resolver.ResolveAll(prices, []string{"usd", "ethereum"})
+
+	assert.InDelta(t, 25.77, prices["bitcoin"]["ethereum"], 0.01)
+}
+
+// TestCrossRateResolverDerivesCryptoCrossFromShortCode checks that a
+// short vs_currency ticker (e.g. "eth") resolves through its aliased
+// token id ("ethereum"), matching the --vs-currencies=usd,eur,btc,eth example
+func TestCrossRateResolverDerivesCryptoCrossFromShortCode(t *testing.T) {
+	resolver := NewCrossRateResolver()
+	prices := map[string]CryptoCurrencyData{
+		"bitcoin":  {"usd": 67000},
+		"ethereum": {"usd": 2600},
+	}
+
+	resolver.ResolveAll(prices, []string{"usd", "eth"})
+
+	assert.InDelta(t, 25.77, prices["bitcoin"]["eth"], 0.01)
+}
+
+// TestCrossRateResolverSkipsUnknownVs checks that an unresolvable
+// vs_currency is left out rather than set to a bogus value
+func TestCrossRateResolverSkipsUnknownVs(t *testing.T) {
+	resolver := NewCrossRateResolver()
+	prices := map[string]CryptoCurrencyData{
+		"bitcoin": {"usd": 67000},
+	}
+
+	resolver.ResolveAll(prices, []string{"usd", "xyz"})
+
+	_, ok := prices["bitcoin"]["xyz"]
+	assert.False(t, ok)
+}
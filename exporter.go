@@ -1,60 +1,90 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
 	defaultRefreshInterval = 30 * time.Second
 	defaultListenAddress   = ":8080"
+	defaultShutdownTimeout = 10 * time.Second
 )
 
-// CryptoCurrencyData struct holds the price of a currency in USD
-type CryptoCurrencyData struct {
-	USD float64 `json:"usd"`
-}
+// CryptoCurrencyData holds the price of a currency keyed by vs_currency
+// fiat (or crypto) code, e.g. {"usd": 67000, "eur": 62000}.
+type CryptoCurrencyData map[string]float64
 
 // CoinGeckoClient struct to manage the HTTP client
 type CoinGeckoClient struct {
-	BaseURL    string
-	HTTPClient *http.Client
+	BaseURL      string
+	VsCurrencies []string
+	HTTPClient   *http.Client
+	backoff      *backoffState
 }
 
-// NewCoinGeckoClient initializes the CoinGecko client
-func NewCoinGeckoClient() *CoinGeckoClient {
+// NewCoinGeckoClient initializes the CoinGecko client. vsCurrencies
+// defaults to ["usd"] when empty. baseInterval seeds the backoff used when
+// CoinGecko's free-tier rate limit kicks in.
+func NewCoinGeckoClient(vsCurrencies []string, baseInterval time.Duration) *CoinGeckoClient {
+	if len(vsCurrencies) == 0 {
+		vsCurrencies = []string{"usd"}
+	}
 	return &CoinGeckoClient{
-		BaseURL: "https://api.coingecko.com/api/v3/simple/price",
+		BaseURL:      "https://api.coingecko.com/api/v3/simple/price",
+		VsCurrencies: vsCurrencies,
 		HTTPClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		backoff: newBackoffState(baseInterval),
 	}
 }
 
-// Global variable to store currency prices (map of token name to CurrencyData) and last refresh timestamp
-var (
-	cryptoCurrencies map[string]CryptoCurrencyData
-	lastRefresh      time.Time
-	mu               sync.RWMutex
-	done             chan bool
-)
+// retryAfterDuration parses a Retry-After header given in seconds. It
+// ignores the HTTP-date form and returns 0 when absent or unparsable,
+// letting the caller fall back to exponential backoff.
+func retryAfterDuration(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
 
-// FetchPrices takes a list of cryptocurrency IDs and fetches their prices in USD
+// FetchPrices takes a list of cryptocurrency IDs and fetches their prices
+// against the configured vs_currencies. On a 429 or 5xx response it honors
+// the Retry-After header (or an exponential backoff otherwise) and returns
+// a *RateLimitError instead of retrying inline.
 func (c *CoinGeckoClient) FetchPrices(ids []string) (map[string]CryptoCurrencyData, error) {
+	if c.backoff == nil {
+		c.backoff = newBackoffState(defaultRefreshInterval)
+	}
+
 	// Join the list of ids into a comma-separated string
 	idList := strings.Join(ids, ",")
+	vsCurrencies := c.VsCurrencies
+	if len(vsCurrencies) == 0 {
+		vsCurrencies = []string{"usd"}
+	}
 
 	// Prepare the full URL with query parameters
-	url := fmt.Sprintf("%s?ids=%s&vs_currencies=USD", c.BaseURL, idList)
+	url := fmt.Sprintf("%s?ids=%s&vs_currencies=%s", c.BaseURL, idList, strings.Join(vsCurrencies, ","))
 
 	// Make the HTTP request
 	resp, err := c.HTTPClient.Get(url)
@@ -63,73 +93,184 @@ func (c *CoinGeckoClient) FetchPrices(ids []string) (map[string]CryptoCurrencyDa
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		rateLimitedTotal.WithLabelValues(c.Name()).Inc()
+		wait := c.backoff.Next(retryAfterDuration(resp.Header.Get("Retry-After")))
+		backoffSecondsGauge.WithLabelValues(c.Name()).Set(wait.Seconds())
+		return nil, &RateLimitError{Provider: c.Name(), Wait: wait}
+	}
+
 	// Parse the JSON response into a map
 	var result map[string]CryptoCurrencyData
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
+	c.backoff.Reset()
 	return result, nil
 }
 
-// MetricsHandler serves the /metrics endpoint with the OpenMetrics formatted data
-func MetricsHandler(w http.ResponseWriter, r *http.Request) {
-	// Lock the map for reading
-	mu.RLock()
-	defer mu.RUnlock()
+// Exporter owns all the mutable state of a running exporter instance, so
+// handlers and refresh loops become methods instead of touching package
+// globals, and tests can spin up an isolated instance per case.
+type Exporter struct {
+	mu               sync.RWMutex
+	cryptoCurrencies map[string]CryptoCurrencyData
+	lastRefresh      time.Time
 
-	// Set the Content-Type header for OpenMetrics
-	w.Header().Set("Content-Type", "text/plain")
+	providers    []PriceProvider
+	ids          []string
+	vsCurrencies []string
+	resolver     *CrossRateResolver
+	priceCache   *PriceCache
+
+	historyStore    *HistoryStore
+	historyClient   *HistoricalClient
+	historyDays     int
+	refreshInterval time.Duration
+
+	done chan struct{}
+}
 
-	// Loop through the currency prices and write the metrics
-	for cryptoCurrency, data := range cryptoCurrencies {
-		fmt.Fprintf(w, "crypto_currency_price_usd{token=\"%s\"} %f\n", cryptoCurrency, data.USD)
+// NewExporter builds an Exporter pre-filled with zero prices for each
+// configured id, so /price has something to report before the first refresh.
+func NewExporter(providers []PriceProvider, ids []string, vsCurrencies []string, historyStore *HistoryStore, historyClient *HistoricalClient, historyDays int, refreshInterval time.Duration) *Exporter {
+	cryptoCurrencies := make(map[string]CryptoCurrencyData, len(ids))
+	for _, id := range ids {
+		cryptoCurrencies[id] = CryptoCurrencyData{"usd": 0.0}
 	}
 
-	// Write the last refresh timestamp as a metric
-	fmt.Fprintf(w, "crypto_currency_last_refresh_seconds %d\n", lastRefresh.Unix())
+	return &Exporter{
+		cryptoCurrencies: cryptoCurrencies,
+		lastRefresh:      time.Unix(0, 0),
+		providers:        providers,
+		ids:              ids,
+		vsCurrencies:     vsCurrencies,
+		resolver:         NewCrossRateResolver(),
+		priceCache:       NewPriceCache(refreshInterval),
+		historyStore:     historyStore,
+		historyClient:    historyClient,
+		historyDays:      historyDays,
+		refreshInterval:  refreshInterval,
+		done:             make(chan struct{}),
+	}
 }
 
 // HealthHandler serves the root endpoint (/) and returns "UP" with HTTP 200 status
-func HealthHandler(w http.ResponseWriter, r *http.Request) {
+func (e *Exporter) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, "UP")
 }
 
-func RefreshPrices(client *CoinGeckoClient, ids []string, interval time.Duration, done <-chan bool) {
+// RefreshPrices runs until Stop is called, periodically refreshing prices
+// from the configured providers and publishing them to the price gauges.
+// Ids whose cache entry is still fresh are reused as-is, so a mix of
+// overlapping currency lists doesn't cause redundant upstream calls.
+func (e *Exporter) RefreshPrices() {
 	for {
-		// Fetch the latest prices
-		prices, err := client.FetchPrices(ids)
-		if err != nil {
-			log.Printf("Error fetching prices: %v", err)
-			time.Sleep(interval)
-			continue
+		toFetch := make([]string, 0, len(e.ids))
+		prices := make(map[string]CryptoCurrencyData, len(e.ids))
+		for _, id := range e.ids {
+			if !e.priceCache.Fresh(id, e.vsCurrencies) {
+				toFetch = append(toFetch, id)
+				continue
+			}
+			data := make(CryptoCurrencyData, len(e.vsCurrencies))
+			for _, vs := range e.vsCurrencies {
+				if price, ok := e.priceCache.Get(id, vs); ok {
+					data[vs] = price
+				}
+			}
+			prices[id] = data
+		}
+
+		wait := e.refreshInterval
+		if len(toFetch) > 0 {
+			fetched, err := FetchFromProviders(e.providers, toFetch)
+			if err != nil {
+				log.Printf("Error fetching prices: %v", err)
+				var rle *RateLimitError
+				if errors.As(err, &rle) {
+					wait = rle.Wait
+				}
+			}
+			// Publish whatever prices were successfully fetched even on a
+			// partial failure, instead of discarding them alongside the error.
+			e.resolver.ResolveAll(fetched, e.vsCurrencies)
+			for id, price := range fetched {
+				prices[id] = price
+			}
 		}
 
-		// Lock the map for writing and update the prices
-		mu.Lock()
-		for id, price := range prices {
-			cryptoCurrencies[id] = price
+		if len(prices) > 0 {
+			e.mu.Lock()
+			for id, price := range prices {
+				e.cryptoCurrencies[id] = price
+				for vs, value := range price {
+					priceGauge.WithLabelValues(id, vs).Set(value)
+					if vs == "usd" {
+						priceUSDGauge.WithLabelValues(id).Set(value)
+					}
+					e.priceCache.Set(id, vs, value)
+				}
+			}
+			e.lastRefresh = time.Now()
+			lastRefreshGauge.Set(float64(e.lastRefresh.Unix()))
+			e.mu.Unlock()
 		}
-		lastRefresh = time.Now() // Update the last refresh time
-		mu.Unlock()
 
-		// Check if the done channel is closed, indicating it's time to stop
 		select {
-		case <-done:
+		case <-e.done:
 			return
-		case <-time.After(interval):
-			// continue after sleeping for the interval duration
+		case <-time.After(wait):
+		}
+	}
+}
+
+// PriceHandler serves GET /price?ids=bitcoin,ethereum with the currently
+// cached prices for the requested ids, without triggering an upstream call.
+func (e *Exporter) PriceHandler(w http.ResponseWriter, r *http.Request) {
+	idsParam := r.URL.Query().Get("ids")
+	if idsParam == "" {
+		http.Error(w, "missing required ids query parameter", http.StatusBadRequest)
+		return
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make(map[string]CryptoCurrencyData)
+	for _, id := range strings.Split(idsParam, ",") {
+		if data, ok := e.cryptoCurrencies[id]; ok {
+			result[id] = data
 		}
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Stop signals the refresh loops to exit at their next opportunity.
+func (e *Exporter) Stop() {
+	close(e.done)
 }
 
 func main() {
 
-	var currencies, listenAddress string
+	var currencies, listenAddress, historyFile, providerNames, vsCurrenciesFlag string
+	var binanceAPIKey, krakenAPIKey string
+	var historyDays int
 
 	flag.StringVar(&currencies, "currencies", "bitcoin,ethereum,iexec-rlc", "List of currency separated by comma to fetch from coingecko.")
 	flag.StringVar(&listenAddress, "listen-address", defaultListenAddress, "Address to listen on.")
+	flag.IntVar(&historyDays, "history-days", defaultHistoryDays, "Number of days of historical prices to backfill and retain.")
+	flag.StringVar(&historyFile, "history-file", historyStateFile, "Path to persist the historical price series across restarts.")
+	flag.StringVar(&providerNames, "providers", "coingecko", "List of price providers separated by comma, tried in order, e.g. coingecko,binance,kraken.")
+	flag.StringVar(&binanceAPIKey, "binance-api-key", "", "API key to use for the Binance provider.")
+	flag.StringVar(&krakenAPIKey, "kraken-api-key", "", "API key to use for the Kraken provider.")
+	flag.StringVar(&vsCurrenciesFlag, "vs-currencies", "usd", "List of vs_currencies separated by comma to price each token against, e.g. usd,eur,btc,eth.")
 	flag.Parse()
 
 	if currencies == "" || listenAddress == "" {
@@ -138,44 +279,82 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize the CoinGecko client
-	client := NewCoinGeckoClient()
+	vsCurrencies := strings.Split(vsCurrenciesFlag, ",")
+
+	// Build the list of price providers to try, in the configured priority order
+	var providers []PriceProvider
+	for _, name := range strings.Split(providerNames, ",") {
+		switch strings.TrimSpace(name) {
+		case "coingecko":
+			providers = append(providers, NewCoinGeckoClient(vsCurrencies, defaultRefreshInterval))
+		case "binance":
+			providers = append(providers, NewBinanceProvider(binanceAPIKey))
+		case "kraken":
+			providers = append(providers, NewKrakenProvider(krakenAPIKey))
+		default:
+			log.Printf("unknown provider %q, ignoring", name)
+		}
+	}
+	if len(providers) == 0 {
+		log.Fatalf("no valid providers configured in --providers=%q", providerNames)
+	}
+
 	ids := strings.Split(currencies, ",")
-	// Initialize the cryptoCurrencies map and pre-fill with the currency names
-	cryptoCurrencies = make(map[string]CryptoCurrencyData)
-	for _, id := range ids {
-		cryptoCurrencies[id] = CryptoCurrencyData{USD: 0.0} // Initial value of 0.0 USD
+
+	// Initialize the history store, restoring any previously persisted
+	// series so a restart doesn't lose the backfilled window.
+	historyStore := NewHistoryStore(historyDays * 24)
+	if err := historyStore.LoadFromFile(historyFile); err != nil {
+		log.Printf("Error loading history state from %s: %v", historyFile, err)
 	}
 
-	// Set the initial last refresh time to 0 (Unix epoch)
-	lastRefresh = time.Unix(0, 0)
+	exporter := NewExporter(providers, ids, vsCurrencies, historyStore, NewHistoricalClient(), historyDays, defaultRefreshInterval)
 
-	// Initialize the done channel for graceful shutdown of the refresh loop
-	done = make(chan bool)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/", exporter.HealthHandler)
+	mux.HandleFunc("/history", exporter.HistoryHandler)
+	mux.HandleFunc("/price", exporter.PriceHandler)
 
-	// Start the background goroutine to refresh prices every 30 seconds
-	go RefreshPrices(client, ids, defaultRefreshInterval, done)
+	server := &http.Server{
+		Addr:    listenAddress,
+		Handler: mux,
+	}
 
-	// Expose the /metrics endpoint
-	http.HandleFunc("/metrics", MetricsHandler)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		exporter.RefreshPrices()
+	}()
+	go func() {
+		defer wg.Done()
+		exporter.RefreshHistory()
+	}()
 
-	// Expose the health check endpoint at /
-	http.HandleFunc("/", HealthHandler)
+	go func() {
+		log.Println("Prometheus exporter running on ", listenAddress)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error starting HTTP server: %v", err)
+		}
+	}()
 
-	// Catch OS signals and close the `done` channel to stop the refresh goroutine.
+	// Catch OS signals and shut everything down gracefully.
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+	sig := <-signalChan
+	log.Printf("Received signal %s, shutting down...", sig)
 
-	go func() {
-		sig := <-signalChan
-		log.Printf("Received signal %s, stopping refresh...", sig)
-		close(done) // This will stop the refresh loop
-		os.Exit(0)
-	}()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down HTTP server: %v", err)
+	}
+
+	exporter.Stop()
+	wg.Wait()
 
-	// Start the HTTP server
-	log.Println("Prometheus exporter running on ", listenAddress)
-	if err := http.ListenAndServe(listenAddress, nil); err != nil {
-		log.Fatalf("Error starting HTTP server: %v", err)
+	if err := exporter.historyStore.SaveToFile(historyFile); err != nil {
+		log.Printf("Error saving history state to %s: %v", historyFile, err)
 	}
 }
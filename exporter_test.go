@@ -6,16 +6,19 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/stretchr/testify/assert"
 )
 
 // TestHealthHandler checks if the health endpoint returns the correct response
 func TestHealthHandler(t *testing.T) {
+	exporter := NewExporter(nil, nil, nil, NewHistoryStore(1), NewHistoricalClient(), 1, time.Second)
+
 	req, err := http.NewRequest("GET", "/", nil)
 	assert.NoError(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(HealthHandler)
+	handler := http.HandlerFunc(exporter.HealthHandler)
 
 	handler.ServeHTTP(rr, req)
 
@@ -23,32 +26,29 @@ func TestHealthHandler(t *testing.T) {
 	assert.Equal(t, "UP", rr.Body.String())
 }
 
-// TestMetricsHandlerWithPrices checks if the /metrics endpoint works after fetching prices
+// TestMetricsHandlerWithPrices checks if the /metrics endpoint exposes the
+// registered gauges with proper OpenMetrics HELP/TYPE lines
 func TestMetricsHandlerWithPrices(t *testing.T) {
-	// Mock some prices and set the last refresh time
-	mu.Lock()
-	cryptoCurrencies = make(map[string]CryptoCurrencyData)
-	cryptoCurrencies["bitcoin"] = CryptoCurrencyData{USD: 67820}
-	cryptoCurrencies["ethereum"] = CryptoCurrencyData{USD: 2624.91}
-	cryptoCurrencies["iexec-rlc"] = CryptoCurrencyData{USD: 1.5}
-	lastRefresh = time.Now()
-	mu.Unlock()
+	priceGauge.WithLabelValues("bitcoin", "usd").Set(67820)
+	priceGauge.WithLabelValues("ethereum", "usd").Set(2624.91)
+	priceGauge.WithLabelValues("iexec-rlc", "usd").Set(1.5)
+	priceUSDGauge.WithLabelValues("bitcoin").Set(67820)
+	lastRefreshGauge.Set(float64(time.Now().Unix()))
 
 	req, err := http.NewRequest("GET", "/metrics", nil)
 	assert.NoError(t, err)
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(MetricsHandler)
-
-	// Call the handler
-	handler.ServeHTTP(rr, req)
+	promhttp.Handler().ServeHTTP(rr, req)
 
-	// Verify the prices are present
 	output := rr.Body.String()
-	assert.Contains(t, output, `crypto_currency_price_usd{token="bitcoin"} 67820.000000`)
-	assert.Contains(t, output, `crypto_currency_price_usd{token="ethereum"} 2624.910000`)
-	assert.Contains(t, output, `crypto_currency_price_usd{token="iexec-rlc"} 1.500000`)
-	assert.Contains(t, output, `crypto_currency_last_refresh_seconds`)
+	assert.Contains(t, output, "# HELP crypto_currency_price")
+	assert.Contains(t, output, "# TYPE crypto_currency_price gauge")
+	assert.Contains(t, output, `crypto_currency_price{token="bitcoin",vs="usd"} 67820`)
+	assert.Contains(t, output, `crypto_currency_price{token="ethereum",vs="usd"} 2624.91`)
+	assert.Contains(t, output, `crypto_currency_price{token="iexec-rlc",vs="usd"} 1.5`)
+	assert.Contains(t, output, "crypto_currency_last_refresh_seconds")
+	assert.Contains(t, output, `crypto_currency_price_usd{token="bitcoin"} 67820`)
 }
 
 // TestFetchPricesMocked checks if FetchPrices correctly retrieves and parses prices from a mock server
@@ -75,9 +75,9 @@ func TestFetchPricesMocked(t *testing.T) {
 	prices, err := client.FetchPrices(ids)
 
 	assert.NoError(t, err)
-	assert.Equal(t, 67820.0, prices["bitcoin"].USD)
-	assert.Equal(t, 2624.91, prices["ethereum"].USD)
-	assert.Equal(t, 1.5, prices["iexec-rlc"].USD)
+	assert.Equal(t, 67820.0, prices["bitcoin"]["usd"])
+	assert.Equal(t, 2624.91, prices["ethereum"]["usd"])
+	assert.Equal(t, 1.5, prices["iexec-rlc"]["usd"])
 }
 
 func TestRefreshPrices(t *testing.T) {
@@ -97,32 +97,89 @@ func TestRefreshPrices(t *testing.T) {
 		BaseURL:    mockServer.URL,
 		HTTPClient: mockServer.Client(),
 	}
-	cryptoCurrencies = make(map[string]CryptoCurrencyData)
 	ids := []string{"bitcoin", "ethereum", "iexec-rlc"}
 
-	// Create a done channel to signal when to stop
-	done := make(chan bool)
+	exporter := NewExporter([]PriceProvider{client}, ids, []string{"usd"}, NewHistoryStore(1), NewHistoricalClient(), 1, 30*time.Second)
 
 	// Start the refresh in a separate goroutine
-	go RefreshPrices(client, ids, 30*time.Second, done)
+	go exporter.RefreshPrices()
 
 	// Allow some time for the prices to be updated
 	time.Sleep(100 * time.Millisecond)
 
 	// Signal to stop the goroutine
-	close(done)
+	exporter.Stop()
 
-	// Now check the updated global prices
-	mu.RLock()
-	defer mu.RUnlock()
+	// Now check the updated prices
+	exporter.mu.RLock()
+	defer exporter.mu.RUnlock()
 
-	// Ensure the global currencyPrices map is updated
-	assert.Equal(t, 67000.0, cryptoCurrencies["bitcoin"].USD)
-	assert.Equal(t, 2600.0, cryptoCurrencies["ethereum"].USD)
-	assert.Equal(t, 1.55, cryptoCurrencies["iexec-rlc"].USD)
+	assert.Equal(t, 67000.0, exporter.cryptoCurrencies["bitcoin"]["usd"])
+	assert.Equal(t, 2600.0, exporter.cryptoCurrencies["ethereum"]["usd"])
+	assert.Equal(t, 1.55, exporter.cryptoCurrencies["iexec-rlc"]["usd"])
 
 	// Ensure the last refresh timestamp is recent
-	assert.WithinDuration(t, time.Now(), lastRefresh, time.Second)
+	assert.WithinDuration(t, time.Now(), exporter.lastRefresh, time.Second)
+}
+
+// TestFetchPricesRateLimited checks that a 429 response is turned into a
+// RateLimitError honoring the Retry-After header, instead of a decode error.
+func TestFetchPricesRateLimited(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer mockServer.Close()
+
+	client := &CoinGeckoClient{
+		BaseURL:    mockServer.URL,
+		HTTPClient: mockServer.Client(),
+	}
+
+	prices, err := client.FetchPrices([]string{"bitcoin"})
+
+	assert.Nil(t, prices)
+	var rle *RateLimitError
+	assert.ErrorAs(t, err, &rle)
+	assert.Equal(t, 5*time.Second, rle.Wait)
+}
+
+// TestPriceHandlerServesCachedValues checks that /price serves whatever is
+// already in memory and never errors on an unknown id.
+func TestPriceHandlerServesCachedValues(t *testing.T) {
+	exporter := NewExporter(nil, []string{"bitcoin"}, []string{"usd"}, NewHistoryStore(1), NewHistoricalClient(), 1, time.Second)
+	exporter.cryptoCurrencies["bitcoin"] = CryptoCurrencyData{"usd": 67000}
+
+	req, err := http.NewRequest("GET", "/price?ids=bitcoin,unknown", nil)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	exporter.PriceHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"bitcoin":{"usd":67000}`)
+	assert.NotContains(t, rr.Body.String(), "unknown")
+}
+
+// TestRefreshPricesPublishesPartialResultsOnError checks that prices a
+// provider did successfully return are still published even when other
+// requested ids remain missing and FetchFromProviders returns an error.
+func TestRefreshPricesPublishesPartialResultsOnError(t *testing.T) {
+	partial := &mockProvider{name: "partial", prices: map[string]CryptoCurrencyData{
+		"bitcoin": {"usd": 67000},
+	}}
+	ids := []string{"bitcoin", "ethereum"}
+
+	exporter := NewExporter([]PriceProvider{partial}, ids, []string{"usd"}, NewHistoryStore(1), NewHistoricalClient(), 1, 30*time.Second)
+
+	go exporter.RefreshPrices()
+	time.Sleep(100 * time.Millisecond)
+	exporter.Stop()
+
+	exporter.mu.RLock()
+	defer exporter.mu.RUnlock()
+
+	assert.Equal(t, 67000.0, exporter.cryptoCurrencies["bitcoin"]["usd"])
 }
 
 // TestInvalidCoinGeckoResponse checks if the client handles an invalid response correctly
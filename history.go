@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHistoryDays = 30
+	historyBucket      = time.Hour
+	historyStateFile   = "history_state.gob"
+)
+
+// PricePoint is a single (timestamp, price) sample in a token's history.
+type PricePoint struct {
+	Timestamp time.Time
+	Price     float64
+}
+
+// HistoricalClient fetches historical USD price series from CoinGecko's
+// market_chart endpoint.
+type HistoricalClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewHistoricalClient initializes the CoinGecko historical client
+func NewHistoricalClient() *HistoricalClient {
+	return &HistoricalClient{
+		BaseURL: "https://api.coingecko.com/api/v3/coins",
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// marketChartResponse mirrors the subset of CoinGecko's market_chart
+// response we care about: a list of [timestamp_ms, price] pairs.
+type marketChartResponse struct {
+	Prices [][2]float64 `json:"prices"`
+}
+
+// FetchHistory retrieves the hourly/daily USD price series for a single
+// token over the given lookback window.
+func (c *HistoricalClient) FetchHistory(id string, days int) ([]PricePoint, error) {
+	url := fmt.Sprintf("%s/%s/market_chart?vs_currency=usd&days=%d", c.BaseURL, id, days)
+
+	resp, err := c.HTTPClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result marketChartResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	points := make([]PricePoint, 0, len(result.Prices))
+	for _, p := range result.Prices {
+		points = append(points, PricePoint{
+			Timestamp: time.UnixMilli(int64(p[0])),
+			Price:     p[1],
+		})
+	}
+
+	return points, nil
+}
+
+// HistoryStore holds a bounded, in-memory ring buffer of price points per
+// token, ordered by timestamp, and supports nearest-neighbor lookups.
+type HistoryStore struct {
+	mu        sync.RWMutex
+	series    map[string][]PricePoint
+	maxPoints int
+}
+
+// NewHistoryStore creates an empty history store. maxPoints bounds the
+// number of points retained per token (oldest points are evicted first).
+func NewHistoryStore(maxPoints int) *HistoryStore {
+	return &HistoryStore{
+		series:    make(map[string][]PricePoint),
+		maxPoints: maxPoints,
+	}
+}
+
+// coalesceBuckets sorts points by timestamp and collapses multiple samples
+// landing in the same historyBucket window into a single point, keeping
+// the most recent sample in each bucket.
+func coalesceBuckets(points []PricePoint) []PricePoint {
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+
+	out := make([]PricePoint, 0, len(points))
+	for _, p := range points {
+		p.Timestamp = p.Timestamp.Truncate(historyBucket)
+		if len(out) > 0 && out[len(out)-1].Timestamp.Equal(p.Timestamp) {
+			out[len(out)-1].Price = p.Price
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// Add appends a price point for a token, bucketing it to historyBucket,
+// keeping the series sorted by timestamp and trimmed to maxPoints.
+func (s *HistoryStore) Add(token string, t time.Time, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	points := coalesceBuckets(append(s.series[token], PricePoint{Timestamp: t, Price: price}))
+	if len(points) > s.maxPoints {
+		points = points[len(points)-s.maxPoints:]
+	}
+
+	s.series[token] = points
+}
+
+// Replace swaps the whole series for a token, e.g. after a backfill,
+// bucketing samples to historyBucket the same way Add does.
+func (s *HistoryStore) Replace(token string, points []PricePoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	points = coalesceBuckets(points)
+	if len(points) > s.maxPoints {
+		points = points[len(points)-s.maxPoints:]
+	}
+	s.series[token] = points
+}
+
+// PriceAt returns the price of a token closest to time t, using a binary
+// search over the sorted timestamps.
+func (s *HistoryStore) PriceAt(token string, t time.Time) (float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := s.series[token]
+	if len(points) == 0 {
+		return 0, fmt.Errorf("no history for token %q", token)
+	}
+
+	i := sort.Search(len(points), func(i int) bool {
+		return !points[i].Timestamp.Before(t)
+	})
+
+	if i == 0 {
+		return points[0].Price, nil
+	}
+	if i == len(points) {
+		return points[len(points)-1].Price, nil
+	}
+
+	before, after := points[i-1], points[i]
+	if t.Sub(before.Timestamp) < after.Timestamp.Sub(t) {
+		return before.Price, nil
+	}
+	return after.Price, nil
+}
+
+// Series returns a copy of the stored points for a token, oldest first.
+func (s *HistoryStore) Series(token string) []PricePoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := s.series[token]
+	out := make([]PricePoint, len(points))
+	copy(out, points)
+	return out
+}
+
+// Snapshot returns a copy of the full series map, for JSON export.
+func (s *HistoryStore) Snapshot() map[string][]PricePoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make(map[string][]PricePoint, len(s.series))
+	for token, points := range s.series {
+		cp := make([]PricePoint, len(points))
+		copy(cp, points)
+		out[token] = cp
+	}
+	return out
+}
+
+// ChangeSince returns the percentage change in price over the given
+// lookback window, e.g. ChangeSince(token, 24*time.Hour) for a 24h change.
+func (s *HistoryStore) ChangeSince(token string, window time.Duration) (float64, error) {
+	s.mu.RLock()
+	points := s.series[token]
+	s.mu.RUnlock()
+
+	if len(points) == 0 {
+		return 0, fmt.Errorf("no history for token %q", token)
+	}
+
+	latest := points[len(points)-1]
+	past, err := s.PriceAt(token, latest.Timestamp.Add(-window))
+	if err != nil {
+		return 0, err
+	}
+	if past == 0 {
+		return 0, fmt.Errorf("zero baseline price for token %q", token)
+	}
+
+	return (latest.Price - past) / past * 100, nil
+}
+
+// MinMax returns the lowest and highest price recorded for a token.
+func (s *HistoryStore) MinMax(token string) (min float64, max float64, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := s.series[token]
+	if len(points) == 0 {
+		return 0, 0, fmt.Errorf("no history for token %q", token)
+	}
+
+	min, max = points[0].Price, points[0].Price
+	for _, p := range points[1:] {
+		if p.Price < min {
+			min = p.Price
+		}
+		if p.Price > max {
+			max = p.Price
+		}
+	}
+	return min, max, nil
+}
+
+// SaveToFile persists the history store to disk as gob-encoded series, so
+// a restart doesn't lose the backfilled window.
+func (s *HistoryStore) SaveToFile(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return gob.NewEncoder(f).Encode(s.series)
+}
+
+// LoadFromFile restores a previously persisted history store. A missing
+// file is not an error: it just means there's nothing to restore yet.
+func (s *HistoryStore) LoadFromFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return gob.NewDecoder(f).Decode(&s.series)
+}
+
+// HistoryHandler serves the /history endpoint with the full in-memory
+// price series for every tracked token, as JSON.
+func (e *Exporter) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(e.historyStore.Snapshot()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RefreshHistory runs until Stop is called, periodically backfilling the
+// history store for each configured token from the historical client.
+func (e *Exporter) RefreshHistory() {
+	for {
+		for _, id := range e.ids {
+			points, err := e.historyClient.FetchHistory(id, e.historyDays)
+			if err != nil {
+				log.Printf("Error fetching history for %s: %v", id, err)
+				continue
+			}
+			e.historyStore.Replace(id, points)
+			updateHistoryGauges(e.historyStore, id)
+		}
+
+		select {
+		case <-e.done:
+			return
+		case <-time.After(e.refreshInterval):
+		}
+	}
+}
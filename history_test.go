@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHistoryStorePriceAt checks nearest-neighbor lookup on a sorted series
+func TestHistoryStorePriceAt(t *testing.T) {
+	store := NewHistoryStore(10)
+	base := time.Unix(0, 0)
+	store.Add("bitcoin", base, 100)
+	store.Add("bitcoin", base.Add(2*time.Hour), 120)
+	store.Add("bitcoin", base.Add(4*time.Hour), 140)
+
+	price, err := store.PriceAt("bitcoin", base.Add(3*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 140.0, price)
+
+	_, err = store.PriceAt("ethereum", base)
+	assert.Error(t, err)
+}
+
+// TestHistoryStoreAddCoalescesSameBucket checks that two samples landing in
+// the same historyBucket window collapse into a single point, keeping the
+// most recent value.
+func TestHistoryStoreAddCoalescesSameBucket(t *testing.T) {
+	store := NewHistoryStore(10)
+	base := time.Unix(0, 0)
+	store.Add("bitcoin", base, 100)
+	store.Add("bitcoin", base.Add(30*time.Minute), 105)
+
+	series := store.Series("bitcoin")
+	assert.Len(t, series, 1)
+	assert.Equal(t, 105.0, series[0].Price)
+}
+
+// TestHistoryStoreChangeSince checks the percentage change over a window
+func TestHistoryStoreChangeSince(t *testing.T) {
+	store := NewHistoryStore(10)
+	base := time.Unix(0, 0)
+	store.Add("bitcoin", base, 100)
+	store.Add("bitcoin", base.Add(24*time.Hour), 110)
+
+	change, err := store.ChangeSince("bitcoin", 24*time.Hour)
+	assert.NoError(t, err)
+	assert.InDelta(t, 10.0, change, 0.001)
+}
+
+// TestHistoryStoreMaxPointsTrim checks the ring buffer evicts oldest points
+func TestHistoryStoreMaxPointsTrim(t *testing.T) {
+	store := NewHistoryStore(2)
+	base := time.Unix(0, 0)
+	store.Add("bitcoin", base, 100)
+	store.Add("bitcoin", base.Add(time.Hour), 110)
+	store.Add("bitcoin", base.Add(2*time.Hour), 120)
+
+	series := store.Series("bitcoin")
+	assert.Len(t, series, 2)
+	assert.Equal(t, 110.0, series[0].Price)
+	assert.Equal(t, 120.0, series[1].Price)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics exposed by the exporter, registered on the default
+// registry and served via promhttp.Handler() at /metrics.
+var (
+	priceGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_currency_price",
+		Help: "Current price of a token against a vs_currency.",
+	}, []string{"token", "vs"})
+
+	// priceUSDGauge keeps the baseline single-currency metric name and
+	// label set alive as an alias of crypto_currency_price{vs="usd"}, so
+	// dashboards/alerts built against the original exporter don't go
+	// silently blank after the multi-fiat vs_currencies rename.
+	priceUSDGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_currency_price_usd",
+		Help: "Current USD price of a token. Deprecated alias of crypto_currency_price{vs=\"usd\"}.",
+	}, []string{"token"})
+
+	lastRefreshGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "crypto_currency_last_refresh_seconds",
+		Help: "Unix timestamp of the last successful price refresh.",
+	})
+
+	providerUpGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_currency_provider_up",
+		Help: "Whether the last fetch attempt against a price provider succeeded.",
+	}, []string{"provider"})
+
+	fetchAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_currency_fetch_attempts_total",
+		Help: "Total number of fetch attempts against a price provider.",
+	}, []string{"provider"})
+
+	fetchErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_currency_fetch_errors_total",
+		Help: "Total number of failed fetch attempts against a price provider.",
+	}, []string{"provider"})
+
+	fetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "crypto_currency_fetch_duration_seconds",
+		Help:    "Latency of upstream price provider requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	rateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "crypto_currency_rate_limited_total",
+		Help: "Total number of upstream responses signaling a rate limit or server error.",
+	}, []string{"provider"})
+
+	backoffSecondsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_currency_backoff_seconds",
+		Help: "Duration of the most recently computed backoff before retrying a provider.",
+	}, []string{"provider"})
+
+	history24hChangeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_currency_price_usd_24h_change",
+		Help: "Percentage change in USD price over the last 24 hours.",
+	}, []string{"token"})
+
+	history7dChangeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_currency_price_usd_7d_change",
+		Help: "Percentage change in USD price over the last 7 days.",
+	}, []string{"token"})
+
+	historyMinGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_currency_price_usd_min",
+		Help: "Lowest USD price recorded in the retained history window.",
+	}, []string{"token"})
+
+	historyMaxGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crypto_currency_price_usd_max",
+		Help: "Highest USD price recorded in the retained history window.",
+	}, []string{"token"})
+)
+
+// updateHistoryGauges refreshes the rolling change/min/max gauges for a
+// token from the given history store, skipping metrics that aren't
+// available yet.
+func updateHistoryGauges(store *HistoryStore, token string) {
+	if change, err := store.ChangeSince(token, 24*time.Hour); err == nil {
+		history24hChangeGauge.WithLabelValues(token).Set(change)
+	}
+	if change, err := store.ChangeSince(token, 7*24*time.Hour); err == nil {
+		history7dChangeGauge.WithLabelValues(token).Set(change)
+	}
+	if min, max, err := store.MinMax(token); err == nil {
+		historyMinGauge.WithLabelValues(token).Set(min)
+		historyMaxGauge.WithLabelValues(token).Set(max)
+	}
+}
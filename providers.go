@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PriceProvider is anything that can fetch prices, keyed by vs_currency,
+// for a set of token ids, identifying itself so callers can report per-provider
+// metrics and pick a fallback order.
+type PriceProvider interface {
+	FetchPrices(ids []string) (map[string]CryptoCurrencyData, error)
+	Name() string
+}
+
+// Name identifies the CoinGecko provider.
+func (c *CoinGeckoClient) Name() string {
+	return "coingecko"
+}
+
+// binanceMapping translates the common token ids used on the CLI (the
+// same ones passed to CoinGecko) to Binance's ticker symbols.
+var binanceMapping = map[string]string{
+	"bitcoin":   "BTCUSDT",
+	"ethereum":  "ETHUSDT",
+	"iexec-rlc": "RLCUSDT",
+}
+
+// krakenMapping translates the common token ids to Kraken's pair names.
+var krakenMapping = map[string]string{
+	"bitcoin":   "XBTUSD",
+	"ethereum":  "ETHUSD",
+	"iexec-rlc": "RLCUSD",
+}
+
+// BinanceProvider fetches prices from Binance's public ticker endpoint.
+type BinanceProvider struct {
+	BaseURL    string
+	APIKey     string
+	Mapping    map[string]string
+	HTTPClient *http.Client
+}
+
+// NewBinanceProvider initializes the Binance provider
+func NewBinanceProvider(apiKey string) *BinanceProvider {
+	return &BinanceProvider{
+		BaseURL: "https://api.binance.com/api/v3/ticker/price",
+		APIKey:  apiKey,
+		Mapping: binanceMapping,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Name identifies the Binance provider.
+func (p *BinanceProvider) Name() string {
+	return "binance"
+}
+
+type binanceTicker struct {
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+}
+
+// FetchPrices takes a list of token ids and fetches their prices in USD
+// from Binance, translating ids to Binance symbols via Mapping.
+func (p *BinanceProvider) FetchPrices(ids []string) (map[string]CryptoCurrencyData, error) {
+	symbolToID := make(map[string]string, len(ids))
+	symbols := make([]string, 0, len(ids))
+	for _, id := range ids {
+		symbol, ok := p.Mapping[id]
+		if !ok {
+			log.Printf("binance: no symbol mapping for %q, skipping", id)
+			continue
+		}
+		symbolToID[symbol] = id
+		symbols = append(symbols, fmt.Sprintf("%q", symbol))
+	}
+
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("binance: no mapped symbols for %v", ids)
+	}
+
+	query := url.QueryEscape(fmt.Sprintf("[%s]", joinStrings(symbols, ",")))
+	reqURL := fmt.Sprintf("%s?symbols=%s", p.BaseURL, query)
+
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.APIKey != "" {
+		req.Header.Set("X-MBX-APIKEY", p.APIKey)
+	}
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tickers []binanceTicker
+	if err := json.NewDecoder(resp.Body).Decode(&tickers); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]CryptoCurrencyData, len(tickers))
+	for _, ticker := range tickers {
+		id, ok := symbolToID[ticker.Symbol]
+		if !ok {
+			continue
+		}
+		price, err := strconv.ParseFloat(ticker.Price, 64)
+		if err != nil {
+			continue
+		}
+		result[id] = CryptoCurrencyData{"usd": price}
+	}
+
+	return result, nil
+}
+
+// KrakenProvider fetches prices from Kraken's public ticker endpoint.
+type KrakenProvider struct {
+	BaseURL    string
+	APIKey     string
+	Mapping    map[string]string
+	HTTPClient *http.Client
+}
+
+// NewKrakenProvider initializes the Kraken provider
+func NewKrakenProvider(apiKey string) *KrakenProvider {
+	return &KrakenProvider{
+		BaseURL: "https://api.kraken.com/0/public/Ticker",
+		APIKey:  apiKey,
+		Mapping: krakenMapping,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Name identifies the Kraken provider.
+func (p *KrakenProvider) Name() string {
+	return "kraken"
+}
+
+type krakenTickerResponse struct {
+	Error  []string                        `json:"error"`
+	Result map[string]krakenTickerPairData `json:"result"`
+}
+
+type krakenTickerPairData struct {
+	Close []string `json:"c"`
+}
+
+// FetchPrices takes a list of token ids and fetches their prices in USD
+// from Kraken, translating ids to Kraken pair names via Mapping.
+func (p *KrakenProvider) FetchPrices(ids []string) (map[string]CryptoCurrencyData, error) {
+	pairToID := make(map[string]string, len(ids))
+	pairs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		pair, ok := p.Mapping[id]
+		if !ok {
+			log.Printf("kraken: no pair mapping for %q, skipping", id)
+			continue
+		}
+		pairToID[pair] = id
+		pairs = append(pairs, pair)
+	}
+
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("kraken: no mapped pairs for %v", ids)
+	}
+
+	reqURL := fmt.Sprintf("%s?pair=%s", p.BaseURL, joinStrings(pairs, ","))
+
+	resp, err := p.HTTPClient.Get(reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tickerResp krakenTickerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tickerResp); err != nil {
+		return nil, err
+	}
+	if len(tickerResp.Error) > 0 {
+		return nil, fmt.Errorf("kraken: %v", tickerResp.Error)
+	}
+
+	result := make(map[string]CryptoCurrencyData, len(tickerResp.Result))
+	for pair, data := range tickerResp.Result {
+		id, ok := pairToID[pair]
+		if !ok || len(data.Close) == 0 {
+			continue
+		}
+		price, err := strconv.ParseFloat(data.Close[0], 64)
+		if err != nil {
+			continue
+		}
+		result[id] = CryptoCurrencyData{"usd": price}
+	}
+
+	return result, nil
+}
+
+func joinStrings(values []string, sep string) string {
+	out := ""
+	for i, v := range values {
+		if i > 0 {
+			out += sep
+		}
+		out += v
+	}
+	return out
+}
+
+// FetchFromProviders tries each provider in priority order for the given
+// ids, falling back to the next provider on error or on a response
+// missing some of the requested ids.
+func FetchFromProviders(providers []PriceProvider, ids []string) (map[string]CryptoCurrencyData, error) {
+	result := make(map[string]CryptoCurrencyData, len(ids))
+	missing := make([]string, len(ids))
+	copy(missing, ids)
+
+	var lastErr error
+	for _, provider := range providers {
+		if len(missing) == 0 {
+			break
+		}
+
+		fetchAttemptsTotal.WithLabelValues(provider.Name()).Inc()
+		start := time.Now()
+		prices, err := provider.FetchPrices(missing)
+		fetchDuration.WithLabelValues(provider.Name()).Observe(time.Since(start).Seconds())
+		if err != nil {
+			log.Printf("%s: error fetching prices: %v", provider.Name(), err)
+			fetchErrorsTotal.WithLabelValues(provider.Name()).Inc()
+			providerUpGauge.WithLabelValues(provider.Name()).Set(0)
+			lastErr = err
+			continue
+		}
+		providerUpGauge.WithLabelValues(provider.Name()).Set(1)
+
+		stillMissing := missing[:0:0]
+		for _, id := range missing {
+			if price, ok := prices[id]; ok {
+				result[id] = price
+			} else {
+				stillMissing = append(stillMissing, id)
+			}
+		}
+		missing = stillMissing
+	}
+
+	if len(missing) > 0 {
+		var rle *RateLimitError
+		if errors.As(lastErr, &rle) {
+			return result, fmt.Errorf("no provider returned prices for %v: %w", missing, rle)
+		}
+		return result, fmt.Errorf("no provider returned prices for %v", missing)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockProvider is a test-only PriceProvider backed by a fixed price map
+// or a forced error, used to exercise the failover logic.
+type mockProvider struct {
+	name   string
+	prices map[string]CryptoCurrencyData
+	err    error
+}
+
+func (m *mockProvider) Name() string { return m.name }
+
+func (m *mockProvider) FetchPrices(ids []string) (map[string]CryptoCurrencyData, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	result := make(map[string]CryptoCurrencyData)
+	for _, id := range ids {
+		if price, ok := m.prices[id]; ok {
+			result[id] = price
+		}
+	}
+	return result, nil
+}
+
+// TestFetchFromProvidersFallsBackOnError checks that a failing provider
+// is skipped in favor of the next one in priority order
+func TestFetchFromProvidersFallsBackOnError(t *testing.T) {
+	failing := &mockProvider{name: "failing", err: errors.New("boom")}
+	backup := &mockProvider{name: "backup", prices: map[string]CryptoCurrencyData{
+		"bitcoin": {"usd": 67000},
+	}}
+
+	result, err := FetchFromProviders([]PriceProvider{failing, backup}, []string{"bitcoin"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 67000.0, result["bitcoin"]["usd"])
+}
+
+// TestFetchFromProvidersFallsBackOnMissingToken checks that a provider
+// missing a requested token doesn't block the next provider from filling it in
+func TestFetchFromProvidersFallsBackOnMissingToken(t *testing.T) {
+	partial := &mockProvider{name: "partial", prices: map[string]CryptoCurrencyData{
+		"bitcoin": {"usd": 67000},
+	}}
+	backup := &mockProvider{name: "backup", prices: map[string]CryptoCurrencyData{
+		"ethereum": {"usd": 2600},
+	}}
+
+	result, err := FetchFromProviders([]PriceProvider{partial, backup}, []string{"bitcoin", "ethereum"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 67000.0, result["bitcoin"]["usd"])
+	assert.Equal(t, 2600.0, result["ethereum"]["usd"])
+}
+
+// TestFetchFromProvidersReturnsErrorWhenAllFail checks that missing ids
+// after exhausting all providers surface as an error
+func TestFetchFromProvidersReturnsErrorWhenAllFail(t *testing.T) {
+	failing := &mockProvider{name: "failing", err: errors.New("boom")}
+
+	_, err := FetchFromProviders([]PriceProvider{failing}, []string{"bitcoin"})
+
+	assert.Error(t, err)
+}